@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// KDF деривирует 32-байтовый ключ элемента по его id. Это позволяет подключать
+// альтернативные реализации (например, на BLAKE2b) вместо стандартной HKDF-SHA256.
+type KDF interface {
+	Derive(id string) [32]byte
+}
+
+// hkdfKDF - реализация KDF по умолчанию: HKDF-SHA256 с мастер-ключом store в
+// качестве секрета, случайной per-store солью и info, завязанным на id элемента.
+// Хранит указатель на store (а не на сам мастер-ключ), так как после Rotate
+// store.masterKey указывает на новый guarded-буфер.
+type hkdfKDF struct {
+	store *SecureStore
+	salt  *[16]byte
+}
+
+func (k *hkdfKDF) Derive(id string) [32]byte {
+	var key [32]byte
+
+	info := append([]byte("safe-mem/item/v1|"), id...)
+	r := hkdf.New(sha256.New, k.store.masterKey.Bytes(), k.salt[:], info)
+	io.ReadFull(r, key[:])
+
+	return key
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+// TestDeriveKeyDeterministic проверяет, что один и тот же id на одном и том же
+// store всегда дает один и тот же ключ элемента.
+func TestDeriveKeyDeterministic(t *testing.T) {
+	store, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store.Close()
+
+	a := store.deriveKey("item-1")
+	b := store.deriveKey("item-1")
+	if a != b {
+		t.Fatalf("deriveKey not deterministic: %x != %x", a, b)
+	}
+}
+
+// TestDeriveKeyUniqueAcrossIDPrefixes проверяет, что у info в HKDF нет
+// неоднозначной конкатенации: разные id, в том числе такие, что один - это
+// конкатенация частей другого ("ab"+"c" против "a"+"bc"), не должны давать
+// совпадающие производные ключи.
+func TestDeriveKeyUniqueAcrossIDPrefixes(t *testing.T) {
+	store, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store.Close()
+
+	ids := []string{
+		"ab", "c", "abc",
+		"a", "bc",
+		"user:1", "user:12", "user1:2", "user12",
+		"", "x",
+		"safe-mem/item/v1|", "safe-mem/item/v1|x",
+	}
+
+	seen := make(map[[32]byte]string, len(ids))
+	for _, id := range ids {
+		key := store.deriveKey(id)
+		if other, ok := seen[key]; ok {
+			t.Fatalf("id %q and %q derived colliding key %x", id, other, key)
+		}
+		seen[key] = id
+	}
+}
+
+// TestDeriveKeyDiffersAcrossStores проверяет, что разные per-store соли дают
+// разные ключи для одного и того же id.
+func TestDeriveKeyDiffersAcrossStores(t *testing.T) {
+	storeA, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer storeA.Close()
+
+	storeB, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer storeB.Close()
+
+	if storeA.deriveKey("item-1") == storeB.deriveKey("item-1") {
+		t.Fatalf("expected different salts to derive different keys for the same id")
+	}
+}
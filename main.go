@@ -1,10 +1,10 @@
 package main
 
 import (
+	"container/heap"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"golang.org/x/sys/unix"
@@ -14,14 +14,30 @@ import (
 	"runtime/debug"
 	"sync"
 	"time"
+
+	"github.com/des1roer/safe-mem/secmem"
 )
 
 type SecureStore struct {
 	mu          sync.RWMutex
-	masterKey   [32]byte            // мастер-ключ в памяти
+	masterKey   *secmem.Buffer      // мастер-ключ, в guarded-памяти (см. пакет secmem)
+	salt        [16]byte            // per-store соль, подмешиваемая в деривацию ключей элементов
+	kdf         KDF                 // деривация ключа элемента по id, по умолчанию HKDF-SHA256
 	dataStore   map[string][]byte   // зашифрованные данные
 	keyCache    map[string][32]byte // ключи для каждого элемента
 	cleanupFunc func()              // функция очистки при завершении
+	header      *passphraseHeader   // параметры Argon2id, если ключ выведен из пароля
+
+	sealed          bool            // true после Seal, пока не восстановлен кворум shares
+	sealThreshold   int             // сколько различных shares нужно для Unseal
+	collectedShares map[byte][]byte // shares, собранные за несколько вызовов Unseal, по x-координате
+
+	metadata    map[string]*entryMeta // TTL/лимит чтений для записей, заданных через Set с опциями
+	expiryHeap  expiryHeap            // min-heap по deadline, на котором спит janitor
+	janitorDone chan struct{}         // закрывается в Close, останавливает janitorLoop
+	janitorWake chan struct{}         // будит janitor, когда добавлена запись с более ранним deadline
+	closed      bool                  // true после Close, чтобы не останавливать janitor дважды
+	onExpire    func(key string)      // если задан, вызывается без s.mu после проактивного удаления по TTL/лимиту чтений (PersistentStore использует это, чтобы пересохранить файл)
 }
 
 func NewSecureStore() (*SecureStore, error) {
@@ -30,10 +46,21 @@ func NewSecureStore() (*SecureStore, error) {
 		keyCache:  make(map[string][32]byte),
 	}
 
-	// Генерируем мастер-ключ
-	if _, err := rand.Read(store.masterKey[:]); err != nil {
+	// Генерируем мастер-ключ в guarded-буфере
+	masterKey, err := secmem.New(32)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(masterKey.Bytes()); err != nil {
 		return nil, err
 	}
+	masterKey.Freeze()
+	store.masterKey = masterKey
+
+	if err := store.initKDF(); err != nil {
+		return nil, err
+	}
+	store.initJanitor()
 
 	// Регистрируем очистку при завершении
 	store.registerCleanup()
@@ -41,35 +68,34 @@ func NewSecureStore() (*SecureStore, error) {
 	return store, nil
 }
 
-// deriveKey создает ключ для конкретного элемента на основе мастер-ключа и идентификатора
-func (s *SecureStore) deriveKey(id string) [32]byte {
-	var key [32]byte
-
-	// Используем HKDF-like подход для деривации ключа
-	h, _ := aes.NewCipher(s.masterKey[:])
-
-	// Создаем уникальный ключ для каждого id
-	idBytes := stringToBytes(id)
-	for i := 0; i < len(key); i += aes.BlockSize {
-		block := make([]byte, aes.BlockSize)
-		copy(block, idBytes)
-		binary.LittleEndian.PutUint64(block[8:], uint64(i))
-
-		encrypted := make([]byte, aes.BlockSize)
-		h.Encrypt(encrypted, block)
-
-		copy(key[i:], encrypted)
+// initKDF генерирует per-store соль и подключает деривацию ключей элементов по
+// умолчанию (HKDF-SHA256). Вызывается из всех конструкторов SecureStore.
+func (s *SecureStore) initKDF() error {
+	if _, err := rand.Read(s.salt[:]); err != nil {
+		return err
 	}
+	s.kdf = &hkdfKDF{store: s, salt: &s.salt}
+	return nil
+}
 
-	return key
+// deriveKey создает ключ для конкретного элемента на основе мастер-ключа и идентификатора
+func (s *SecureStore) deriveKey(id string) [32]byte {
+	return s.kdf.Derive(id)
 }
 
 ////////
 
-func (s *SecureStore) Set(key string, data []byte) error {
+// Set шифрует и сохраняет data под key. Необязательные opts (WithTTL,
+// WithMaxReads, WithNotAfter) ограничивают время жизни или число чтений
+// записи; без них запись живет, пока ее не удалят явно.
+func (s *SecureStore) Set(key string, data []byte, opts ...Option) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if s.sealed {
+		return ErrSealed
+	}
+
 	// Деривируем ключ для этого элемента
 	itemKey := s.deriveKey(key)
 
@@ -86,40 +112,106 @@ func (s *SecureStore) Set(key string, data []byte) error {
 	// Немедленно очищаем оригинальные данные
 	s.wipeBytes(data)
 
+	// Предыдущие TTL/лимит чтений (если были) больше не относятся к новому значению
+	delete(s.metadata, key)
+
+	if len(opts) > 0 {
+		meta := newEntryMeta(key)
+		for _, opt := range opts {
+			opt(meta)
+		}
+		s.metadata[key] = meta
+
+		if !meta.deadline.IsZero() {
+			heap.Push(&s.expiryHeap, meta)
+			select {
+			case s.janitorWake <- struct{}{}:
+			default:
+			}
+		}
+	}
+
 	return nil
 }
 
-func (s *SecureStore) Get(key string) ([]byte, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// Get возвращает расшифрованные данные в guarded-буфере (см. пакет secmem):
+// буфер заблокирован в памяти и окружен guard-страницами, а не является
+// обычным срезом в куче Go. Вызывающий код обязан вызвать buf.Destroy(),
+// когда данные больше не нужны. Возвращает ErrSealed, пока store запечатан
+// через Seal. Если у записи задан TTL/NotAfter и срок истек, запись
+// удаляется и возвращается "not found"; если задан лимит чтений, он
+// атомарно уменьшается, и по достижении нуля запись тоже удаляется.
+func (s *SecureStore) Get(key string) (*secmem.Buffer, error) {
+	s.mu.Lock()
+
+	if s.sealed {
+		s.mu.Unlock()
+		return nil, ErrSealed
+	}
+
+	if meta, ok := s.metadata[key]; ok && meta.expired(time.Now()) {
+		s.deleteLocked(key)
+		s.mu.Unlock()
+		s.notifyExpired(key)
+		return nil, errors.New("key not found")
+	}
 
 	encrypted, exists := s.dataStore[key]
 	if !exists {
-		return nil, false
+		s.mu.Unlock()
+		return nil, errors.New("key not found")
 	}
 
 	itemKey, keyExists := s.keyCache[key]
 	if !keyExists {
-		return nil, false
+		s.mu.Unlock()
+		return nil, errors.New("key not found")
 	}
 
 	// Дешифруем данные
 	decrypted, err := s.decryptData(encrypted, itemKey)
 	if err != nil {
-		return nil, false
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	buf, err := secmem.New(len(decrypted))
+	if err != nil {
+		s.wipeBytes(decrypted)
+		s.mu.Unlock()
+		return nil, err
+	}
+	copy(buf.Bytes(), decrypted)
+	s.wipeBytes(decrypted)
+
+	// notifyExpired вызывается только после s.mu.Unlock ниже: onExpire для
+	// PersistentStore вызывает Save, который сам берет ps.mu, и удержание s.mu
+	// здесь привело бы к самоблокировке.
+	expiredNow := false
+	if meta, ok := s.metadata[key]; ok && meta.remainingReads >= 0 {
+		meta.remainingReads--
+		if meta.remainingReads <= 0 {
+			s.deleteLocked(key)
+			expiredNow = true
+		}
 	}
 
-	return decrypted, true
+	s.mu.Unlock()
+	if expiredNow {
+		s.notifyExpired(key)
+	}
+
+	return buf, nil
 }
 
 func (s *SecureStore) GetAndUse(key string, fn func([]byte) error) error {
-	data, exists := s.Get(key)
-	if !exists {
-		return errors.New("key not found")
+	buf, err := s.Get(key)
+	if err != nil {
+		return err
 	}
-	defer s.wipeBytes(data) // Очищаем после использования
+	defer buf.Destroy() // Очищаем после использования
 
-	return fn(data)
+	return fn(buf.Bytes())
 }
 
 ////////
@@ -175,6 +267,14 @@ func (s *SecureStore) Delete(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.deleteLocked(key)
+}
+
+// deleteLocked - тело Delete без захвата s.mu; вызывается из мест, которые
+// уже держат write lock (Get при истечении TTL/лимита чтений и expireDue
+// janitor'а, который удаляет записи в той же критической секции, где
+// проверил их срок).
+func (s *SecureStore) deleteLocked(key string) {
 	if encrypted, exists := s.dataStore[key]; exists {
 		s.wipeBytes(encrypted)
 		delete(s.dataStore, key)
@@ -185,6 +285,8 @@ func (s *SecureStore) Delete(key string) {
 		delete(s.keyCache, key)
 	}
 
+	delete(s.metadata, key)
+
 	runtime.GC()
 }
 
@@ -205,7 +307,14 @@ func (s *SecureStore) Wipe() {
 	}
 
 	// Очищаем мастер-ключ
-	s.wipeKey(&s.masterKey)
+	if s.masterKey != nil {
+		s.masterKey.Destroy()
+	}
+
+	for key := range s.metadata {
+		delete(s.metadata, key)
+	}
+	s.expiryHeap = nil
 
 	runtime.GC()
 }
@@ -291,7 +400,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer store.Wipe() // Очистка при завершении
+	defer store.Close() // Останавливает janitor и очищает store
 
 	// Сохраняем чувствительные данные
 	secret := []byte("my_super_secret_password")
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/des1roer/safe-mem/secmem"
+)
+
+// Argon2Params задает параметры KDF для деривации мастер-ключа из пароля.
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32 // в КиБ
+	Threads uint8
+}
+
+// DefaultArgon2Params возвращает разумные параметры по умолчанию (~64 MiB, 3 прохода).
+func DefaultArgon2Params() Argon2Params {
+	return Argon2Params{
+		Time:    3,
+		Memory:  64 * 1024,
+		Threads: 4,
+	}
+}
+
+// passphraseHeader хранится рядом со store и позволяет воспроизвести мастер-ключ
+// по тому же паролю при повторном открытии.
+type passphraseHeader struct {
+	Salt   [16]byte
+	Params Argon2Params
+}
+
+// deriveMasterKey выводит мастер-ключ из пароля через Argon2id и кладет его в
+// guarded-буфер (см. пакет secmem), затирая промежуточный срез в куче Go.
+func deriveMasterKey(pass []byte, salt [16]byte, params Argon2Params) (*secmem.Buffer, error) {
+	derived := argon2.IDKey(pass, salt[:], params.Time, params.Memory, params.Threads, 32)
+	defer func() {
+		for i := range derived {
+			derived[i] = 0
+		}
+	}()
+
+	buf, err := secmem.New(32)
+	if err != nil {
+		return nil, err
+	}
+	copy(buf.Bytes(), derived)
+	buf.Freeze()
+
+	return buf, nil
+}
+
+// NewSecureStoreFromPassphrase создает SecureStore, чей мастер-ключ выводится из
+// пользовательского пароля через Argon2id, а не из rand.Read. Соль генерируется
+// случайно и сохраняется в заголовке store, поэтому тот же пароль воспроизводит
+// тот же мастер-ключ при повторном открытии.
+func NewSecureStoreFromPassphrase(pass []byte, params Argon2Params) (*SecureStore, error) {
+	if len(pass) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	var salt [16]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		return nil, err
+	}
+
+	store := &SecureStore{
+		dataStore: make(map[string][]byte),
+		keyCache:  make(map[string][32]byte),
+		header:    &passphraseHeader{Salt: salt, Params: params},
+	}
+
+	masterKey, err := deriveMasterKey(pass, salt, params)
+	if err != nil {
+		return nil, err
+	}
+	store.masterKey = masterKey
+
+	if err := store.initKDF(); err != nil {
+		return nil, err
+	}
+	store.initJanitor()
+
+	store.registerCleanup()
+
+	return store, nil
+}
+
+// Rotate выводит новый мастер-ключ из newPass, перешифровывает каждое значение под
+// новыми производными ключами элементов и затирает старый мастер-ключ и старые
+// ключи элементов. Заголовок с параметрами Argon2 обновляется, чтобы повторное
+// открытие с новым паролем снова воспроизводило ключ.
+func (s *SecureStore) Rotate(newPass []byte) error {
+	if len(newPass) == 0 {
+		return errors.New("passphrase must not be empty")
+	}
+	if s.header == nil {
+		return errors.New("store was not opened from a passphrase")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sealed {
+		return ErrSealed
+	}
+
+	var newSalt [16]byte
+	if _, err := io.ReadFull(rand.Reader, newSalt[:]); err != nil {
+		return err
+	}
+
+	newMasterKey, err := deriveMasterKey(newPass, newSalt, s.header.Params)
+	if err != nil {
+		return err
+	}
+
+	oldMasterKey := s.masterKey
+	s.masterKey = newMasterKey // deriveKey ниже уже использует новый мастер-ключ
+
+	newKeyCache := make(map[string][32]byte, len(s.keyCache))
+	newDataStore := make(map[string][]byte, len(s.dataStore))
+
+	// Старые шифротексты/ключи не трогаем, пока newDataStore/newKeyCache не
+	// построены целиком: s.dataStore остается читаемым под старым мастер-ключом,
+	// так что ошибка на любой записи оставляет store в исходном состоянии.
+	for id, encrypted := range s.dataStore {
+		oldItemKey := s.keyCache[id]
+
+		plain, err := s.decryptData(encrypted, oldItemKey)
+		if err != nil {
+			s.masterKey = oldMasterKey
+			newMasterKey.Destroy()
+			return err
+		}
+
+		newItemKey := s.deriveKey(id)
+		reencrypted, err := s.encryptData(plain, newItemKey)
+		s.wipeBytes(plain)
+		if err != nil {
+			s.masterKey = oldMasterKey
+			newMasterKey.Destroy()
+			return err
+		}
+
+		newDataStore[id] = reencrypted
+		newKeyCache[id] = newItemKey
+	}
+
+	oldDataStore := s.dataStore
+	oldKeyCache := s.keyCache
+
+	s.dataStore = newDataStore
+	s.keyCache = newKeyCache
+	s.header.Salt = newSalt
+
+	for id, encrypted := range oldDataStore {
+		s.wipeBytes(encrypted)
+		if oldItemKey, ok := oldKeyCache[id]; ok {
+			s.wipeKey(&oldItemKey)
+		}
+	}
+	oldMasterKey.Destroy()
+
+	return nil
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/des1roer/safe-mem/secmem"
+)
+
+// ErrCorrupt возвращается, когда HMAC файла store не совпадает с ожидаемым,
+// то есть файл был изменен или поврежден.
+var ErrCorrupt = errors.New("persistent store: file is corrupt or tampered with")
+
+const persistentStoreVersion byte = 1
+
+// persistentEntry - формат на диске для одного элемента: nonce и шифротекст,
+// оба в base64, как в go-ethereum aes_gcm_storage.
+type persistentEntry struct {
+	IV string `json:"iv"`
+	C  string `json:"c"`
+}
+
+// persistentFile - содержимое файла целиком: версия формата, записи и HMAC
+// над остальной частью файла для обнаружения подмены.
+type persistentFile struct {
+	Version byte                       `json:"version"`
+	Salt    string                     `json:"salt"`
+	Entries map[string]persistentEntry `json:"entries"`
+	MAC     string                     `json:"mac"`
+}
+
+// PersistentStore - SecureStore, который при записи сбрасывает зашифрованный
+// dataStore в JSON-файл. Ключи элементов никогда не попадают на диск в открытом
+// виде: на диске хранятся только шифротексты, полученные под ключами,
+// выведенными из мастер-ключа и id элемента через deriveKey, так что для
+// разблокировки файла достаточно мастер-ключа/пароля.
+type PersistentStore struct {
+	*SecureStore
+	path    string
+	dirty   bool
+	syncNow bool // если true, каждый Set/Delete сразу вызывает Save
+}
+
+// Open открывает (или создает) PersistentStore по заданному пути под заданным
+// мастер-ключом. Если файл уже существует, его содержимое проверяется по HMAC
+// и загружается в dataStore (шифротексты как есть, без расшифровки), а ключи
+// элементов выводятся заново через deriveKey в keyCache.
+func Open(path string, key []byte) (*PersistentStore, error) {
+	if len(key) != 32 {
+		return nil, errors.New("persistent store: key must be 32 bytes")
+	}
+
+	store := &SecureStore{
+		dataStore: make(map[string][]byte),
+		keyCache:  make(map[string][32]byte),
+	}
+
+	masterKey, err := secmem.New(32)
+	if err != nil {
+		return nil, err
+	}
+	copy(masterKey.Bytes(), key)
+	masterKey.Freeze()
+	store.masterKey = masterKey
+
+	if err := store.initKDF(); err != nil {
+		return nil, err
+	}
+
+	ps := &PersistentStore{SecureStore: store, path: path, syncNow: true}
+
+	// onExpire проводится до initJanitor, чтобы janitor не мог сработать с
+	// незаданным хуком: иначе проактивное истечение TTL было бы видно только
+	// в памяти и не пересохраняло бы файл.
+	store.onExpire = ps.handleExpired
+	store.initJanitor()
+	store.registerCleanup()
+
+	if _, err := os.Stat(path); err == nil {
+		if err := ps.load(); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return ps, nil
+}
+
+func (ps *PersistentStore) fileMAC(salt string, entries map[string]persistentEntry) ([]byte, error) {
+	payload, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, ps.masterKey.Bytes())
+	mac.Write([]byte{persistentStoreVersion})
+	mac.Write([]byte(salt))
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+func (ps *PersistentStore) load() error {
+	raw, err := os.ReadFile(ps.path)
+	if err != nil {
+		return err
+	}
+
+	var pf persistentFile
+	if err := json.Unmarshal(raw, &pf); err != nil {
+		return err
+	}
+
+	if pf.Version != persistentStoreVersion {
+		return fmt.Errorf("persistent store: unsupported file version %d (want %d)", pf.Version, persistentStoreVersion)
+	}
+
+	expectedMAC, err := ps.fileMAC(pf.Salt, pf.Entries)
+	if err != nil {
+		return err
+	}
+	gotMAC, err := base64.StdEncoding.DecodeString(pf.MAC)
+	if err != nil || !hmac.Equal(expectedMAC, gotMAC) {
+		return ErrCorrupt
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(pf.Salt)
+	if err != nil || len(salt) != len(ps.salt) {
+		return ErrCorrupt
+	}
+	copy(ps.salt[:], salt)
+
+	for id, entry := range pf.Entries {
+		iv, err := base64.StdEncoding.DecodeString(entry.IV)
+		if err != nil {
+			return ErrCorrupt
+		}
+		c, err := base64.StdEncoding.DecodeString(entry.C)
+		if err != nil {
+			return ErrCorrupt
+		}
+
+		itemKey := ps.deriveKey(id)
+		encrypted := append(iv, c...)
+		ps.dataStore[id] = encrypted
+		ps.keyCache[id] = itemKey
+	}
+
+	return nil
+}
+
+// Set шифрует и сохраняет data, как и SecureStore.Set (включая опции
+// WithTTL/WithMaxReads/WithNotAfter), затем помечает store "грязным" и при
+// syncNow сразу пишет файл на диск.
+func (ps *PersistentStore) Set(key string, data []byte, opts ...Option) error {
+	if err := ps.SecureStore.Set(key, data, opts...); err != nil {
+		return err
+	}
+	return ps.markDirtyAndMaybeSync()
+}
+
+// Delete удаляет элемент, как и SecureStore.Delete, затем помечает store
+// "грязным" и при syncNow сразу пишет файл на диск.
+func (ps *PersistentStore) Delete(key string) error {
+	ps.SecureStore.Delete(key)
+	return ps.markDirtyAndMaybeSync()
+}
+
+// handleExpired - это store.onExpire для PersistentStore: вызывается janitor'ом
+// или Get после того, как запись уже проактивно удалена из dataStore/keyCache
+// по TTL/лимиту чтений. Ключ в файле на диске к этому моменту уже не нужен,
+// поэтому просто пересохраняем store, как это делает Delete.
+func (ps *PersistentStore) handleExpired(key string) {
+	if err := ps.markDirtyAndMaybeSync(); err != nil {
+		log.Printf("persistent store: failed to persist expiry of %q: %v", key, err)
+	}
+}
+
+// markDirtyAndMaybeSync помечает store "грязным" и, если включен syncNow,
+// сразу вызывает Save. dirty/syncNow читаются и пишутся под ps.mu, так как
+// embedded SecureStore документирован как потокобезопасный, а Set/Delete/
+// janitor могут дергать эти поля конкурентно.
+func (ps *PersistentStore) markDirtyAndMaybeSync() error {
+	ps.mu.Lock()
+	ps.dirty = true
+	syncNow := ps.syncNow
+	ps.mu.Unlock()
+
+	if syncNow {
+		return ps.Save()
+	}
+	return nil
+}
+
+// Save сериализует текущий dataStore в JSON-файл по ps.path, добавляя байт
+// версии формата и HMAC над содержимым для обнаружения подмены.
+func (ps *PersistentStore) Save() error {
+	ps.mu.RLock()
+	entries := make(map[string]persistentEntry, len(ps.dataStore))
+	for id, encrypted := range ps.dataStore {
+		nonceSize := 12 // размер nonce AES-GCM
+		if len(encrypted) < nonceSize {
+			ps.mu.RUnlock()
+			return errors.New("persistent store: malformed ciphertext")
+		}
+		entries[id] = persistentEntry{
+			IV: base64.StdEncoding.EncodeToString(encrypted[:nonceSize]),
+			C:  base64.StdEncoding.EncodeToString(encrypted[nonceSize:]),
+		}
+	}
+	ps.mu.RUnlock()
+
+	salt := base64.StdEncoding.EncodeToString(ps.salt[:])
+
+	mac, err := ps.fileMAC(salt, entries)
+	if err != nil {
+		return err
+	}
+
+	pf := persistentFile{
+		Version: persistentStoreVersion,
+		Salt:    salt,
+		Entries: entries,
+		MAC:     base64.StdEncoding.EncodeToString(mac),
+	}
+
+	raw, err := json.Marshal(pf)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(ps.path, raw, 0600); err != nil {
+		return err
+	}
+
+	ps.mu.Lock()
+	ps.dirty = false
+	ps.mu.Unlock()
+	return nil
+}
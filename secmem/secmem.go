@@ -0,0 +1,124 @@
+// Package secmem предоставляет буферы в защищенной памяти (в стиле memguard):
+// страницы блокируются от свопинга через mlock и окружаются guard-страницами
+// с PROT_NONE, так что выход за границы выровненной по страницам области
+// данных немедленно приводит к падению, а не к тихому чтению/перезаписи
+// соседней памяти. Остаток последней страницы за пределами size байт guard-
+// страницей не защищен. Это защита на уровне отдельных страниц, помимо
+// process-wide mlockall из lockMemory в main.go (вызов lockMemory в
+// registerCleanup закомментирован).
+package secmem
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ErrDestroyed возвращается при обращении к уже уничтоженному буферу.
+var ErrDestroyed = errors.New("secmem: buffer already destroyed")
+
+// Buffer - область памяти фиксированного размера, выделенная отдельно от
+// обычной кучи Go и окруженная guard-страницами.
+type Buffer struct {
+	mu        sync.Mutex
+	region    []byte // вся область, включая guard-страницы
+	data      []byte // полезная нагрузка внутри region, ровно size байт
+	dataPages []byte // data, выровненный до границ страниц (то, к чему применяется mprotect/mlock)
+	destroyed bool
+}
+
+// New выделяет буфер как минимум на size байт, блокирует его в памяти и
+// окружает guard-страницами. Возвращенный буфер доступен для чтения и записи
+// (Freeze/Melt переключают режим защиты). size == 0 допустим и возвращает
+// валидный, обычным образом Destroy-able буфер с пустым Bytes() - под него
+// все равно выделяется и блокируется одна страница данных, просто
+// пользовательский срез над ней имеет нулевую длину.
+func New(size int) (*Buffer, error) {
+	if size < 0 {
+		return nil, errors.New("secmem: size must not be negative")
+	}
+
+	pageSize := os.Getpagesize()
+	allocSize := size
+	if allocSize == 0 {
+		allocSize = pageSize
+	}
+	dataPages := ((allocSize + pageSize - 1) / pageSize) * pageSize
+
+	region, err := allocGuarded(dataPages)
+	if err != nil {
+		return nil, err
+	}
+
+	dataStart := pageSize
+	b := &Buffer{
+		region:    region,
+		dataPages: region[dataStart : dataStart+dataPages],
+		data:      region[dataStart : dataStart+size],
+	}
+
+	if err := lock(b.dataPages); err != nil {
+		_ = freeGuarded(region)
+		return nil, err
+	}
+
+	runtime.SetFinalizer(b, (*Buffer).Destroy)
+
+	return b, nil
+}
+
+// Bytes возвращает слайс полезной нагрузки буфера. Слайс остается валидным
+// до вызова Destroy.
+func (b *Buffer) Bytes() []byte {
+	return b.data
+}
+
+// Freeze переводит буфер в режим "только чтение" (PROT_READ).
+func (b *Buffer) Freeze() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.destroyed {
+		return ErrDestroyed
+	}
+	return protectReadOnly(b.dataPages)
+}
+
+// Melt переводит буфер обратно в режим чтения и записи (PROT_READ|PROT_WRITE).
+func (b *Buffer) Melt() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.destroyed {
+		return ErrDestroyed
+	}
+	return protectReadWrite(b.dataPages)
+}
+
+// Destroy затирает содержимое буфера нулями, снимает mlock и освобождает
+// память. Безопасно вызывать повторно. Также регистрируется как
+// runtime.SetFinalizer, чтобы забытые буферы все равно затирались.
+func (b *Buffer) Destroy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.destroyed {
+		return nil
+	}
+
+	if err := protectReadWrite(b.dataPages); err == nil {
+		for i := range b.dataPages {
+			b.dataPages[i] = 0
+		}
+	}
+
+	_ = unlock(b.dataPages)
+	err := freeGuarded(b.region)
+
+	b.destroyed = true
+	b.data = nil
+	b.dataPages = nil
+	b.region = nil
+
+	runtime.SetFinalizer(b, nil)
+
+	return err
+}
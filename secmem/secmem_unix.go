@@ -0,0 +1,56 @@
+//go:build linux || darwin
+
+package secmem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocGuarded выделяет анонимную mmap-область размером pageSize + dataPages +
+// pageSize и немедленно закрывает первую и последнюю страницу через
+// PROT_NONE, превращая их в guard-страницы вокруг данных в середине.
+func allocGuarded(dataPages int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	total := pageSize + dataPages + pageSize
+
+	region, err := unix.Mmap(-1, 0, total, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+
+	leadGuard := region[:pageSize]
+	trailGuard := region[pageSize+dataPages:]
+
+	if err := unix.Mprotect(leadGuard, unix.PROT_NONE); err != nil {
+		_ = unix.Munmap(region)
+		return nil, err
+	}
+	if err := unix.Mprotect(trailGuard, unix.PROT_NONE); err != nil {
+		_ = unix.Munmap(region)
+		return nil, err
+	}
+
+	return region, nil
+}
+
+func lock(data []byte) error {
+	return unix.Mlock(data)
+}
+
+func unlock(data []byte) error {
+	return unix.Munlock(data)
+}
+
+func protectReadOnly(data []byte) error {
+	return unix.Mprotect(data, unix.PROT_READ)
+}
+
+func protectReadWrite(data []byte) error {
+	return unix.Mprotect(data, unix.PROT_READ|unix.PROT_WRITE)
+}
+
+func freeGuarded(region []byte) error {
+	return unix.Munmap(region)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package secmem
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// allocGuarded - Windows-аналог mmap+mprotect(PROT_NONE): резервирует и
+// коммитит область VirtualAlloc, затем переводит первую и последнюю страницы
+// в PAGE_NOACCESS, оставляя данные в середине читаемыми/записываемыми.
+func allocGuarded(dataPages int) ([]byte, error) {
+	pageSize := os.Getpagesize()
+	total := pageSize + dataPages + pageSize
+
+	addr, err := windows.VirtualAlloc(0, uintptr(total), windows.MEM_COMMIT|windows.MEM_RESERVE, windows.PAGE_READWRITE)
+	if err != nil {
+		return nil, err
+	}
+
+	region := unsafe.Slice((*byte)(unsafe.Pointer(addr)), total)
+
+	var oldProtect uint32
+	if err := windows.VirtualProtect(addr, uintptr(pageSize), windows.PAGE_NOACCESS, &oldProtect); err != nil {
+		_ = windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, err
+	}
+	trailAddr := addr + uintptr(pageSize+dataPages)
+	if err := windows.VirtualProtect(trailAddr, uintptr(pageSize), windows.PAGE_NOACCESS, &oldProtect); err != nil {
+		_ = windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+		return nil, err
+	}
+
+	return region, nil
+}
+
+func lock(data []byte) error {
+	return windows.VirtualLock(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+}
+
+func unlock(data []byte) error {
+	return windows.VirtualUnlock(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+}
+
+func protectReadOnly(data []byte) error {
+	var oldProtect uint32
+	return windows.VirtualProtect(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), windows.PAGE_READONLY, &oldProtect)
+}
+
+func protectReadWrite(data []byte) error {
+	var oldProtect uint32
+	return windows.VirtualProtect(uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), windows.PAGE_READWRITE, &oldProtect)
+}
+
+// freeGuarded освобождает всю зарезервированную область разом: Windows не
+// позволяет частично освобождать VirtualAlloc-регион, поэтому base-адрес
+// вычисляется обратно от начала region.
+func freeGuarded(region []byte) error {
+	addr := uintptr(unsafe.Pointer(&region[0]))
+	return windows.VirtualFree(addr, 0, windows.MEM_RELEASE)
+}
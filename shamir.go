@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/des1roer/safe-mem/secmem"
+)
+
+// ErrSealed возвращается Get/Set, пока мастер-ключ store расщеплен на shares
+// вызовом Seal и еще не восстановлен через Unseal.
+var ErrSealed = errors.New("secure store is sealed")
+
+// shamirShareLen - длина одного share: 1 байт x-координаты + 32 байта
+// (по одному y на каждый байт 32-байтового мастер-ключа).
+const shamirShareLen = 1 + 32
+
+// --- Арифметика GF(2^8) по модулю x^8+x^4+x^3+x+1 (как в AES) ---
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfLog[x] = byte(i)
+		x = gfMulSlow(x, 3)
+	}
+	for i := 255; i < 510; i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// gfMulSlow умножает в GF(2^8) через сложение-и-сдвиг; используется только
+// для построения таблиц логарифмов/экспонент в init.
+func gfMulSlow(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		if b&1 != 0 {
+			p ^= a
+		}
+		hiBitSet := a & 0x80
+		a <<= 1
+		if hiBitSet != 0 {
+			a ^= 0x1B
+		}
+		b >>= 1
+	}
+	return p
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	if b == 0 {
+		panic("shamir: division by zero in GF(2^8)")
+	}
+	return gfExp[(int(gfLog[a])+255-int(gfLog[b]))%255]
+}
+
+func gfAdd(a, b byte) byte {
+	return a ^ b // сложение/вычитание в GF(2^8) - это xor
+}
+
+// gfEvalPolynomial вычисляет многочлен с coeffs[0] как свободным членом в
+// точке x методом Горнера.
+func gfEvalPolynomial(coeffs []byte, x byte) byte {
+	var result byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		result = gfAdd(gfMul(result, x), coeffs[i])
+	}
+	return result
+}
+
+// gfLagrangeAtZero восстанавливает f(0) по точкам (xs[i], ys[i]) через
+// интерполяцию Лагранжа над GF(2^8).
+func gfLagrangeAtZero(xs, ys []byte) byte {
+	var result byte
+	for i := range xs {
+		num, den := byte(1), byte(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			num = gfMul(num, xs[j])
+			den = gfMul(den, gfAdd(xs[i], xs[j]))
+		}
+		result = gfAdd(result, gfMul(ys[i], gfDiv(num, den)))
+	}
+	return result
+}
+
+// Seal расщепляет 32-байтовый мастер-ключ на shares штук по схеме Шамира
+// (многочлен степени threshold-1 на байт, вычисленный в различных ненулевых
+// x) так, что любые threshold из них восстанавливают ключ, а меньшее
+// количество не дает о нем никакой информации. После генерации shares
+// мастер-ключ в памяти затирается, и store переходит в запечатанное
+// состояние: Get/Set возвращают ErrSealed до вызова Unseal.
+func (s *SecureStore) Seal(threshold, shares int) ([][]byte, error) {
+	if threshold < 2 || shares < threshold || shares > 255 {
+		return nil, errors.New("shamir: invalid threshold/shares")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sealed {
+		return nil, ErrSealed
+	}
+
+	secret := make([]byte, 32)
+	copy(secret, s.masterKey.Bytes())
+	defer wipeSlice(secret)
+
+	out := make([][]byte, shares)
+	for i := range out {
+		out[i] = make([]byte, shamirShareLen)
+		out[i][0] = byte(i + 1) // x-координата, ненулевая и различная для каждого share
+	}
+
+	coeffs := make([]byte, threshold)
+	defer wipeSlice(coeffs)
+
+	for byteIdx := 0; byteIdx < 32; byteIdx++ {
+		coeffs[0] = secret[byteIdx]
+		if _, err := io.ReadFull(rand.Reader, coeffs[1:]); err != nil {
+			return nil, err
+		}
+
+		for i := range out {
+			out[i][1+byteIdx] = gfEvalPolynomial(coeffs, out[i][0])
+		}
+	}
+
+	s.masterKey.Destroy()
+	s.masterKey = nil
+	s.sealed = true
+	s.sealThreshold = threshold
+	s.collectedShares = nil
+
+	return out, nil
+}
+
+// Unseal принимает shares (можно по одному за вызов, накапливая их между
+// вызовами) и, как только собрано threshold различных x-координат,
+// восстанавливает мастер-ключ через интерполяцию Лагранжа и снимает
+// запечатанное состояние. Повторная передача уже известной x-координаты
+// отклоняется как дубликат.
+func (s *SecureStore) Unseal(shares [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.sealed {
+		return errors.New("shamir: store is not sealed")
+	}
+
+	if s.collectedShares == nil {
+		s.collectedShares = make(map[byte][]byte)
+	}
+
+	for _, share := range shares {
+		if len(share) != shamirShareLen {
+			return errors.New("shamir: malformed share")
+		}
+
+		x := share[0]
+		if x == 0 {
+			return errors.New("shamir: invalid share x-coordinate")
+		}
+		if _, dup := s.collectedShares[x]; dup {
+			return errors.New("shamir: duplicate share x-coordinate")
+		}
+
+		cp := make([]byte, shamirShareLen)
+		copy(cp, share)
+		s.collectedShares[x] = cp
+	}
+
+	if len(s.collectedShares) < s.sealThreshold {
+		return nil // ждем остальные shares
+	}
+
+	xs := make([]byte, 0, len(s.collectedShares))
+	for x := range s.collectedShares {
+		xs = append(xs, x)
+	}
+
+	secret := make([]byte, 32)
+	defer wipeSlice(secret)
+	ys := make([]byte, len(xs))
+	for byteIdx := 0; byteIdx < 32; byteIdx++ {
+		for i, x := range xs {
+			ys[i] = s.collectedShares[x][1+byteIdx]
+		}
+		secret[byteIdx] = gfLagrangeAtZero(xs, ys)
+	}
+
+	masterKey, err := secmem.New(32)
+	if err != nil {
+		return err
+	}
+	copy(masterKey.Bytes(), secret)
+	masterKey.Freeze()
+
+	for x, buf := range s.collectedShares {
+		wipeSlice(buf)
+		delete(s.collectedShares, x)
+	}
+
+	s.masterKey = masterKey
+	s.sealed = false
+	s.sealThreshold = 0
+	s.collectedShares = nil
+
+	return nil
+}
+
+func wipeSlice(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
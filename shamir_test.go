@@ -0,0 +1,129 @@
+package main
+
+import "testing"
+
+// TestSealUnsealRoundTrip проверяет, что Seal/Unseal восстанавливают тот же
+// мастер-ключ при сборе ровно threshold shares.
+func TestSealUnsealRoundTrip(t *testing.T) {
+	store, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store.Close()
+
+	original := make([]byte, 32)
+	copy(original, store.masterKey.Bytes())
+
+	shares, err := store.Seal(3, 5)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !store.sealed {
+		t.Fatalf("expected store to be sealed after Seal")
+	}
+
+	if err := store.Unseal(shares[:3]); err != nil {
+		t.Fatalf("Unseal: %v", err)
+	}
+	if store.sealed {
+		t.Fatalf("expected store to be unsealed after threshold shares")
+	}
+
+	if got := store.masterKey.Bytes(); string(got) != string(original) {
+		t.Fatalf("recovered master key does not match original: got %x want %x", got, original)
+	}
+}
+
+// TestUnsealBelowThresholdRevealsNothing проверяет, что threshold-1 shares не
+// восстанавливают мастер-ключ: store остается запечатанным, masterKey
+// остается nil, ошибки нет (store просто ждет недостающие shares).
+func TestUnsealBelowThresholdRevealsNothing(t *testing.T) {
+	store, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store.Close()
+
+	shares, err := store.Seal(3, 5)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if err := store.Unseal(shares[:2]); err != nil {
+		t.Fatalf("Unseal with threshold-1 shares returned error: %v", err)
+	}
+	if !store.sealed {
+		t.Fatalf("store unsealed with fewer than threshold shares")
+	}
+	if store.masterKey != nil {
+		t.Fatalf("master key recovered with fewer than threshold shares")
+	}
+}
+
+// TestShareBelowThresholdRevealsNothingInformationTheoretically демонстрирует
+// ядро гарантии схемы Шамира для threshold=2: имея всего одну точку (x, y) на
+// многочлене степени 1, для ЛЮБОГО кандидата секрета s найдется коэффициент
+// наклона, при котором многочлен проходит через (0, s) и (x, y) одновременно -
+// то есть единственный share не сужает множество возможных секретов вообще.
+func TestShareBelowThresholdRevealsNothingInformationTheoretically(t *testing.T) {
+	const secret = byte(0x42)
+	const slope = byte(0x07)
+	coeffs := []byte{secret, slope}
+
+	x := byte(5)
+	y := gfEvalPolynomial(coeffs, x)
+
+	for candidate := 0; candidate < 256; candidate++ {
+		target := byte(candidate)
+
+		// gfEvalPolynomial(x) = target + recoveredSlope*x (mod GF(2^8)), решаем
+		// относительно recoveredSlope так же, как это делает gfLagrangeAtZero.
+		recoveredSlope := gfDiv(gfAdd(y, target), x)
+
+		if got := gfEvalPolynomial([]byte{target, recoveredSlope}, x); got != y {
+			t.Fatalf("candidate secret %#x is not consistent with the single known share (x=%d,y=%#x): got %#x", target, x, y, got)
+		}
+		if got := gfEvalPolynomial([]byte{target, recoveredSlope}, 0); got != target {
+			t.Fatalf("reconstructed polynomial does not evaluate to the candidate secret at x=0")
+		}
+	}
+}
+
+// TestUnsealDuplicateXRejected проверяет, что повторная x-координата (две доли
+// от одного и того же участника) отклоняется как дубликат, а не просто
+// перезаписывает ранее собранную долю.
+func TestUnsealDuplicateXRejected(t *testing.T) {
+	store, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store.Close()
+
+	shares, err := store.Seal(3, 5)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	err = store.Unseal([][]byte{shares[0], shares[0]})
+	if err == nil {
+		t.Fatalf("expected error for duplicate x-coordinate in a single Unseal call")
+	}
+
+	store2, err := NewSecureStore()
+	if err != nil {
+		t.Fatalf("NewSecureStore: %v", err)
+	}
+	defer store2.Close()
+
+	shares2, err := store2.Seal(3, 5)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if err := store2.Unseal(shares2[:1]); err != nil {
+		t.Fatalf("Unseal: %v", err)
+	}
+	if err := store2.Unseal(shares2[:1]); err == nil {
+		t.Fatalf("expected error for duplicate x-coordinate across separate Unseal calls")
+	}
+}
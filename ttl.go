@@ -0,0 +1,189 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Option настраивает срок жизни и лимит чтений записи, передаваемой в Set.
+type Option func(*entryMeta)
+
+// entryMeta хранит ограничения TTL/числа чтений для одной записи dataStore.
+// Указатель на entryMeta также лежит в expiryHeap, поэтому его нельзя
+// перезаписывать по значению - только заменять целиком в s.metadata.
+type entryMeta struct {
+	key            string
+	deadline       time.Time // нулевое значение - нет TTL/NotAfter
+	remainingReads int       // отрицательное значение - лимит чтений не задан
+}
+
+func newEntryMeta(key string) *entryMeta {
+	return &entryMeta{key: key, remainingReads: -1}
+}
+
+func (m *entryMeta) expired(now time.Time) bool {
+	return !m.deadline.IsZero() && !m.deadline.After(now)
+}
+
+// withDeadline выставляет более раннюю из уже заданной и новой границы,
+// так что WithTTL и WithNotAfter можно комбинировать на одной записи.
+func (m *entryMeta) withDeadline(t time.Time) {
+	if m.deadline.IsZero() || t.Before(m.deadline) {
+		m.deadline = t
+	}
+}
+
+// WithTTL ограничивает время жизни записи относительно момента вызова Set.
+func WithTTL(d time.Duration) Option {
+	return func(m *entryMeta) { m.withDeadline(time.Now().Add(d)) }
+}
+
+// WithMaxReads ограничивает число успешных Get/GetAndUse для записи; по
+// достижении лимита запись удаляется так же, как обычный Delete.
+func WithMaxReads(n int) Option {
+	return func(m *entryMeta) { m.remainingReads = n }
+}
+
+// WithNotAfter задает абсолютный момент времени, после которого запись
+// считается истекшей.
+func WithNotAfter(t time.Time) Option {
+	return func(m *entryMeta) { m.withDeadline(t) }
+}
+
+// expiryHeap - min-heap по deadline, на котором спит janitor. Может
+// содержать устаревшие записи (удаленные или перезаписанные Set) - они
+// отбрасываются по несовпадению с текущим s.metadata[key] при просмотре.
+type expiryHeap []*entryMeta
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *expiryHeap) Push(x any) {
+	*h = append(*h, x.(*entryMeta))
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// initJanitor готовит структуры TTL/лимита чтений и запускает фоновую
+// горутину, которая просыпается по min-heap сроков истечения и проактивно
+// затирает записи, не дожидаясь обращения к ним через Get. Вызывается из
+// всех конструкторов SecureStore.
+func (s *SecureStore) initJanitor() {
+	s.metadata = make(map[string]*entryMeta)
+	s.janitorWake = make(chan struct{}, 1)
+	s.janitorDone = make(chan struct{})
+	go s.janitorLoop()
+}
+
+func (s *SecureStore) janitorLoop() {
+	const idleWait = time.Hour
+
+	timer := time.NewTimer(idleWait)
+	defer timer.Stop()
+
+	for {
+		wait := s.nextExpiryWait(idleWait)
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+			s.expireDue()
+		case <-s.janitorWake:
+			if !timer.Stop() {
+				<-timer.C
+			}
+		case <-s.janitorDone:
+			return
+		}
+	}
+}
+
+// nextExpiryWait возвращает, сколько ждать до ближайшего известного deadline,
+// попутно выбрасывая из кучи записи, уже не совпадающие с s.metadata.
+func (s *SecureStore) nextExpiryWait(idleWait time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.expiryHeap.Len() > 0 {
+		top := s.expiryHeap[0]
+		if cur, ok := s.metadata[top.key]; !ok || cur != top {
+			heap.Pop(&s.expiryHeap)
+			continue
+		}
+
+		wait := time.Until(top.deadline)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait
+	}
+
+	return idleWait
+}
+
+// expireDue удаляет истекшие записи, не выпуская s.mu между проверкой
+// deadline/identity и самим удалением. Если бы мы сначала собрали ключи и
+// удаляли их отдельным вызовом Delete после разблокировки, конкурентный Set
+// с тем же ключом в этом окне записал бы новое значение, которое затем
+// стерло бы запаздывающее удаление - поэтому deleteLocked вызывается прямо
+// здесь, в той же критической секции, где подтверждена identity top==cur.
+func (s *SecureStore) expireDue() {
+	s.mu.Lock()
+	now := time.Now()
+	var expired []string
+
+	for s.expiryHeap.Len() > 0 {
+		top := s.expiryHeap[0]
+		cur, ok := s.metadata[top.key]
+		if !ok || cur != top {
+			heap.Pop(&s.expiryHeap)
+			continue
+		}
+		if !top.expired(now) {
+			break
+		}
+		heap.Pop(&s.expiryHeap)
+		s.deleteLocked(top.key)
+		expired = append(expired, top.key)
+	}
+	s.mu.Unlock()
+
+	for _, key := range expired {
+		s.notifyExpired(key)
+	}
+}
+
+// notifyExpired вызывает s.onExpire (если задан) вне s.mu после того, как
+// запись уже удалена проактивным удалением по TTL/лимиту чтений. Используется
+// PersistentStore, чтобы пересохранить файл и не оставлять истекший секрет
+// зашифрованным, но восстановимым на диске.
+func (s *SecureStore) notifyExpired(key string) {
+	if s.onExpire != nil {
+		s.onExpire(key)
+	}
+}
+
+// Close останавливает janitor-горутину и затирает store через Wipe.
+// Безопасно вызывать повторно.
+func (s *SecureStore) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	close(s.janitorDone)
+	s.Wipe()
+
+	return nil
+}